@@ -4,8 +4,9 @@ import (
 	"container/heap"
 	"flag"
 	"fmt"
-	"log"
-	"runtime/debug"
+	"math"
+	"sync/atomic"
+	"time"
 )
 
 var WRRatio = flag.Float64("wr", 2.0, "Ratio of sampled write conflicts and sampled writes to sampled reads at which to move a piece of data to split.  Default 3")
@@ -13,20 +14,281 @@ var WRRatio = flag.Float64("wr", 2.0, "Ratio of sampled write conflicts and samp
 var ConflictWeight = flag.Float64("cw", 2.0, "Weight given to conflicts over writes\n")
 var ReadWeight = flag.Float64("rw", 0.5, "Weight given to reads over stashes\n")
 
+var DecayAlpha = flag.Float64("decay", 0.5, "Multiplicative decay applied to every write and conflict counter on each Tick, so stale contention fades out of Candidates\n")
+var TickInterval = flag.Duration("tick-interval", time.Second, "Expected interval between Candidates.Tick calls; Tick scales DecayAlpha by the actual elapsed time over this so an uneven tick cadence still decays proportionally\n")
+
+// LowWaterTicks is how many consecutive ticks a dd-marked key's decayed
+// ratio must stay below WRRatio before Tick demotes it.
+var LowWaterTicks = flag.Int("demote-ticks", 3, "Consecutive low-ratio ticks required before a dd key is demoted\n")
+
+var CandMaxKeys = flag.Int("cand-max-keys", 0, "Maximum number of keys a Candidates may track before it evicts the lowest-ratio non-dd entry; 0 means unbounded\n")
+var CandMaxBytes = flag.Int64("cand-max-bytes", 0, "Approximate maximum bytes a Candidates.m may occupy before it evicts the lowest-ratio non-dd entry; 0 means unbounded\n")
+
+// statBytes approximates what one tracked key costs: the OneStat struct,
+// its two heap slots, and the map bucket holding it. It only needs to be
+// in the right ballpark for -cand-max-bytes to be a useful knob.
+const statBytes = 128
+
+// OpStat is one KeyType's accumulated writes and conflicts on a key; a
+// key keeps one OpStat per kind of write it has seen. Its Op is exported so an OpPolicy
+// can inspect the full set observed on a key.
+type OpStat struct {
+	Op        KeyType
+	Writes    float64
+	Conflicts float64
+}
+
+// opBits is a fast-path membership test for KeyType values in [0, 64);
+// ops outside that range fall back to scanning OneStat.ops directly,
+// which is fine since a key mixing ops is the rare case.
+type opBits uint64
+
+func (b opBits) has(op KeyType) bool {
+	if op < 0 || op >= 64 {
+		return false
+	}
+	return b&(1<<uint(op)) != 0
+}
+
+func (b opBits) set(op KeyType) opBits {
+	if op < 0 || op >= 64 {
+		return b
+	}
+	return b | (1 << uint(op))
+}
+
+// OpPolicy resolves which KeyType a key with more than one observed op
+// should be split under. It returns false to decline the split entirely
+// when the observed ops don't compose into a single commutative summary.
+type OpPolicy func(ops []OpStat) (KeyType, bool)
+
+// DefaultOpPolicy only allows a split when exactly one op has ever been
+// observed on the key, since arbitrary op combinations don't commute.
+// Callers that know e.g. MAX and SUM can share a split representation
+// should set Candidates.Policy to something more permissive.
+var DefaultOpPolicy OpPolicy = func(ops []OpStat) (KeyType, bool) {
+	if len(ops) == 1 {
+		return ops[0].Op, true
+	}
+	return -1, false
+}
+
+// MetricKind says which of Metric's value fields is populated.
+type MetricKind int
+
+const (
+	KindUint64 MetricKind = iota
+	KindFloat64Histogram
+)
+
+// Histogram is a set of counts bucketed by an upper bound per bucket;
+// the last count is the overflow bucket for values above Buckets[len-1].
+type Histogram struct {
+	Buckets []float64
+	Counts  []uint64
+}
+
+// Metric is one named, typed sample pulled from Candidates.Sample. Set
+// Name (from Descriptions) before calling Sample; Sample fills in Kind
+// and the matching value field.
+type Metric struct {
+	Name      string
+	Kind      MetricKind
+	Uint64    uint64
+	Histogram Histogram
+}
+
+// Description documents one name Candidates.Sample understands.
+type Description struct {
+	Name        string
+	Kind        MetricKind
+	Description string
+}
+
+var candidatesDescriptions = []Description{
+	{"ddtxn/candidates/tracked_keys", KindUint64, "Number of keys currently tracked in Candidates.m"},
+	{"ddtxn/candidates/heap_len", KindUint64, "Number of keys currently riding in Candidates.h"},
+	{"ddtxn/candidates/promotions_total", KindUint64, "Cumulative count of keys Candidates has marked dd"},
+	{"ddtxn/candidates/demotions_total", KindUint64, "Cumulative count of keys Tick has demoted out of dd"},
+	{"ddtxn/candidates/evictions_total", KindUint64, "Cumulative count of keys evicted under -cand-max-keys/-cand-max-bytes"},
+	{"ddtxn/candidates/ratio", KindFloat64Histogram, "Distribution of OneStat.ratio() across tracked keys, bucketed around WRRatio"},
+}
+
+// Descriptions returns the set of metric names Candidates.Sample
+// understands, so tools can discover what's available without guessing.
+func Descriptions() []Description {
+	out := make([]Description, len(candidatesDescriptions))
+	copy(out, candidatesDescriptions)
+	return out
+}
+
+// ratioBuckets returns the upper bound of each non-overflow ratio
+// bucket, scaled off the current WRRatio so the histogram stays
+// meaningful as that flag is tuned.
+func ratioBuckets() []float64 {
+	base := *WRRatio
+	mult := []float64{0.125, 0.25, 0.5, 1, 2, 4, 8}
+	out := make([]float64, len(mult))
+	for i, m := range mult {
+		out[i] = base * m
+	}
+	return out
+}
+
+func ratioBucket(r float64, buckets []float64) int {
+	for i, b := range buckets {
+		if r <= b {
+			return i
+		}
+	}
+	return len(buckets)
+}
+
+// candidatesSnapshot is what Sample actually reads; Tick rebuilds and
+// atomically swaps it in, so Sample never touches c.m or c.h directly.
+type candidatesSnapshot struct {
+	trackedKeys  uint64
+	heapLen      uint64
+	promotions   uint64
+	demotions    uint64
+	evictions    uint64
+	ratioBuckets []float64
+	ratioCounts  []uint64
+}
+
+// refreshSnapshot rebuilds the double-buffered snapshot Sample reads.
+// Called at the end of Tick, never on the Read/Write/Conflict fast path.
+func (c *Candidates) refreshSnapshot() {
+	buckets := ratioBuckets()
+	counts := make([]uint64, len(buckets)+1)
+	for _, o := range c.m {
+		counts[ratioBucket(o.ratio(), buckets)]++
+	}
+	c.snap.Store(&candidatesSnapshot{
+		trackedKeys:  uint64(len(c.m)),
+		heapLen:      uint64(len(*c.h)),
+		promotions:   uint64(c.Promotions),
+		demotions:    uint64(c.Demotions),
+		evictions:    uint64(c.Evictions),
+		ratioBuckets: buckets,
+		ratioCounts:  counts,
+	})
+}
+
+// Sample populates dst in place, matching each slot's pre-set Name
+// against Descriptions and filling in its Kind and value; slots whose
+// Name isn't recognized are left untouched. It reads the snapshot most
+// recently published by Tick, so it never blocks a concurrent Read,
+// Write, or Conflict call.
+func (c *Candidates) Sample(dst []Metric) {
+	v, _ := c.snap.Load().(*candidatesSnapshot)
+	if v == nil {
+		v = &candidatesSnapshot{ratioBuckets: ratioBuckets()}
+	}
+	for i := range dst {
+		switch dst[i].Name {
+		case "ddtxn/candidates/tracked_keys":
+			dst[i].Kind = KindUint64
+			dst[i].Uint64 = v.trackedKeys
+		case "ddtxn/candidates/heap_len":
+			dst[i].Kind = KindUint64
+			dst[i].Uint64 = v.heapLen
+		case "ddtxn/candidates/promotions_total":
+			dst[i].Kind = KindUint64
+			dst[i].Uint64 = v.promotions
+		case "ddtxn/candidates/demotions_total":
+			dst[i].Kind = KindUint64
+			dst[i].Uint64 = v.demotions
+		case "ddtxn/candidates/evictions_total":
+			dst[i].Kind = KindUint64
+			dst[i].Uint64 = v.evictions
+		case "ddtxn/candidates/ratio":
+			dst[i].Kind = KindFloat64Histogram
+			dst[i].Histogram = Histogram{Buckets: v.ratioBuckets, Counts: v.ratioCounts}
+		}
+	}
+}
+
 type OneStat struct {
 	k         Key
-	op        KeyType
 	reads     float64
 	writes    float64
 	conflicts float64
 	stash     float64
 	index     int
+
+	// ops holds one entry per distinct KeyType write op observed on k;
+	// bits mirrors ops for O(1) membership testing of in-range values.
+	// Most keys see exactly one op, so ops rarely grows past length 1.
+	ops  []OpStat
+	bits opBits
+
+	// dd marks that this key is currently running in split/dd mode.
+	// lowTicks counts consecutive Tick calls where the decayed ratio
+	// has been below WRRatio; it resets to 0 whenever the ratio climbs
+	// back above the threshold.
+	dd       bool
+	lowTicks int
+
+	// vindex is this entry's position in Candidates.v, the min-by-ratio
+	// heap used to find eviction victims in O(log N); -1 if the entry
+	// is pinned (dd, or currently riding in h) and so isn't in v.
+	vindex int
 }
 
 func (o *OneStat) ratio() float64 {
 	return float64((*ConflictWeight)*o.conflicts+o.writes) / (float64((*ReadWeight)*o.reads) + float64(o.stash))
 }
 
+// opStat returns o's counters for op, or nil if op hasn't been observed.
+func (o *OneStat) opStat(op KeyType) *OpStat {
+	if op >= 0 && op < 64 && !o.bits.has(op) {
+		return nil
+	}
+	for i := range o.ops {
+		if o.ops[i].Op == op {
+			return &o.ops[i]
+		}
+	}
+	return nil
+}
+
+// recordOp adds dw writes and dc conflicts to op's counters, creating a
+// new OpStat the first time op is seen on this key.
+func (o *OneStat) recordOp(op KeyType, dw, dc float64) {
+	s := o.opStat(op)
+	if s == nil {
+		o.ops = append(o.ops, OpStat{Op: op})
+		o.bits = o.bits.set(op)
+		s = &o.ops[len(o.ops)-1]
+	}
+	s.Writes += dw
+	s.Conflicts += dc
+}
+
+// mergeOps folds another OneStat's per-op counters into o, used when
+// combining the same key's stats from multiple workers.
+func (o *OneStat) mergeOps(src []OpStat) {
+	for _, s := range src {
+		d := o.opStat(s.Op)
+		if d == nil {
+			o.ops = append(o.ops, OpStat{Op: s.Op})
+			o.bits = o.bits.set(s.Op)
+			d = &o.ops[len(o.ops)-1]
+		}
+		d.Writes += s.Writes
+		d.Conflicts += s.Conflicts
+	}
+}
+
+// Ops returns a copy of the per-op write/conflict breakdown observed on
+// this key, for an OpPolicy to decide how (or whether) to split it.
+func (o *OneStat) Ops() []OpStat {
+	out := make([]OpStat, len(o.ops))
+	copy(out, o.ops)
+	return out
+}
+
 // m is very big; it should have every key the worker sampled.  h is a
 // heap of all keys we deemed interesting enough to add to the heap.
 // This includes keys where the ratio is high enough to consider
@@ -37,9 +299,163 @@ func (o *OneStat) ratio() float64 {
 // Since we limit what we add to h, it doesn't really have to be a
 // heap.  But one could imagine eliminating m and only looking at the
 // top set of things in the heap instead.
+//
+// v is a companion min-by-ratio heap over the entries in m that are
+// neither dd-marked nor currently riding in h; it lets evictIfNeeded
+// find the coldest evictable key in O(log N) once m grows past
+// -cand-max-keys or -cand-max-bytes. Evictions counts keys dropped this
+// way.
 type Candidates struct {
-	m map[Key]*OneStat
-	h *StatsHeap
+	m          map[Key]*OneStat
+	h          *StatsHeap
+	v          *victimHeap
+	Evictions  int64
+	Promotions int64
+	Demotions  int64
+
+	// Policy resolves the KeyType to split under when a key has more
+	// than one observed op; nil means DefaultOpPolicy.
+	Policy OpPolicy
+
+	// snap holds the most recent *candidatesSnapshot, refreshed at the
+	// end of Tick. Sample reads it via atomic.Value so it never takes a
+	// lock against the Read/Write/Conflict fast path.
+	snap atomic.Value
+
+	// lastTick is when Tick last ran, so the next call can scale decay
+	// by how much time actually passed instead of assuming a fixed
+	// cadence. Zero until the first call.
+	lastTick time.Time
+}
+
+// promote marks o dd and counts the transition, no-op if already dd.
+func (c *Candidates) promote(o *OneStat) {
+	if !o.dd {
+		o.dd = true
+		c.Promotions++
+	}
+}
+
+// demote clears o's dd bit and counts the transition, no-op otherwise.
+func (c *Candidates) demote(o *OneStat) {
+	if o.dd {
+		o.dd = false
+		c.Demotions++
+	}
+}
+
+// SplitOp returns the KeyType the caller should use to split k, applying
+// Candidates.Policy (or DefaultOpPolicy) to every op observed on it. The
+// second return is false if k is unknown or the policy declines to split.
+func (c *Candidates) SplitOp(k Key) (KeyType, bool) {
+	o, ok := c.m[k]
+	if !ok {
+		return -1, false
+	}
+	policy := c.Policy
+	if policy == nil {
+		policy = DefaultOpPolicy
+	}
+	return policy(o.Ops())
+}
+
+// victims lazily allocates v so a Candidates built as a bare struct
+// literal (as MergeAll and friends do) still works.
+func (c *Candidates) victims() *victimHeap {
+	if c.v == nil {
+		c.v = &victimHeap{}
+	}
+	return c.v
+}
+
+// Prealloc sizes the tracking map and eviction heap for a worker that
+// already knows roughly how many keys it'll see, so it doesn't pay for
+// map growth on the hot path. Safe to call after m already has entries:
+// v is rebuilt from m's current contents rather than replaced out from
+// under their vindex, which would otherwise orphan every existing entry.
+func (c *Candidates) Prealloc(expectedKeys int) {
+	if c.m == nil {
+		c.m = make(map[Key]*OneStat, expectedKeys)
+	}
+	vh := make(victimHeap, 0, expectedKeys)
+	c.v = &vh
+	for _, o := range c.m {
+		o.vindex = -1
+		c.syncVictim(o)
+	}
+}
+
+// newStat enforces -cand-max-keys and -cand-max-bytes by evicting the
+// coldest evictable entry first if needed, then creates and inserts a
+// fresh OneStat for k into both m and v.
+func (c *Candidates) newStat(k Key, reads, writes, conflicts, stash float64) *OneStat {
+	c.evictIfNeeded()
+	o := &OneStat{k: k, reads: reads, writes: writes, conflicts: conflicts, stash: stash, index: -1, vindex: -1}
+	c.m[k] = o
+	heap.Push(c.victims(), o)
+	return o
+}
+
+// evictIfNeeded drops the coldest evictable entries until m is back
+// under the configured caps, or until every remaining key is pinned
+// (dd-marked or in h), in which case it gives up and lets m grow.
+func (c *Candidates) evictIfNeeded() {
+	for (*CandMaxKeys > 0 && len(c.m) >= *CandMaxKeys) || (*CandMaxBytes > 0 && int64(len(c.m))*statBytes >= *CandMaxBytes) {
+		v := c.victims()
+		if v.Len() == 0 {
+			return
+		}
+		worst := heap.Pop(v).(*OneStat)
+		delete(c.m, worst.k)
+		c.Evictions++
+	}
+}
+
+// syncVictim keeps o's membership in v in sync with its pinned status.
+// dd-marked and heap-resident entries are pinned out of v; everything
+// else is kept in v, ordered by ratio, as an eviction candidate. Call
+// this after any change to o's ratio, dd flag, or h membership.
+func (c *Candidates) syncVictim(o *OneStat) {
+	v := c.victims()
+	if o.dd || o.index != -1 {
+		if o.vindex != -1 {
+			heap.Remove(v, o.vindex)
+		}
+		return
+	}
+	if o.vindex == -1 {
+		heap.Push(v, o)
+	} else {
+		heap.Fix(v, o.vindex)
+	}
+}
+
+// victimHeap orders evictable OneStats ascending by ratio, so the
+// coldest entry - the one evictIfNeeded should drop first - is always
+// the root.
+type victimHeap []*OneStat
+
+func (v victimHeap) Len() int           { return len(v) }
+func (v victimHeap) Less(i, j int) bool { return v[i].ratio() < v[j].ratio() }
+func (v victimHeap) Swap(i, j int) {
+	v[i], v[j] = v[j], v[i]
+	v[i].vindex = i
+	v[j].vindex = j
+}
+
+func (v *victimHeap) Push(x interface{}) {
+	n := len(*v)
+	*v = append(*v, x.(*OneStat))
+	(*v)[n].vindex = n
+}
+
+func (v *victimHeap) Pop() interface{} {
+	old := *v
+	n := len(old)
+	x := old[n-1]
+	x.vindex = -1
+	*v = old[0 : n-1]
+	return x
 }
 
 func (c *Candidates) Merge(c2 *Candidates) {
@@ -47,28 +463,150 @@ func (c *Candidates) Merge(c2 *Candidates) {
 		o2 := heap.Pop(c2.h).(*OneStat)
 		o, ok := c.m[o2.k]
 		if !ok {
-			c.m[o2.k] = &OneStat{k: o2.k, op: o2.op, reads: 0, writes: 0, conflicts: 0, stash: 0, index: -1}
-			o = c.m[o2.k]
+			o = c.newStat(o2.k, 0, 0, 0, 0)
 		}
 		o.reads += o2.reads
 		o.writes += o2.writes
 		o.conflicts += o2.conflicts
 		o.stash += o2.stash
+		o.mergeOps(o2.ops)
+		if o2.dd {
+			c.promote(o)
+		}
 		c.h.update(o)
+		c.syncVictim(o)
 	}
 }
 
+// mergeItem is one slot in the k-way merge's outer priority queue: the
+// current head of one worker's StatsHeap, tagged with which worker it
+// came from so we know where to pull the next entry once this one pops.
+type mergeItem struct {
+	stat   *OneStat
+	worker int
+}
+
+type mergeQueue []*mergeItem
+
+func (q mergeQueue) Len() int            { return len(q) }
+func (q mergeQueue) Less(i, j int) bool  { return q[i].stat.ratio() > q[j].stat.ratio() }
+func (q mergeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *mergeQueue) Push(x interface{}) { *q = append(*q, x.(*mergeItem)) }
+func (q *mergeQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[0 : n-1]
+	return x
+}
+
+// mergeInto folds o2 into dst, creating a fresh entry the first time
+// o2.k is seen. It inserts directly into dst.m/dst.v rather than going
+// through newStat, since dst is a merge result bounded by its caller's
+// topM, not a per-worker Candidates subject to -cand-max-keys/-cand-max-bytes.
+func mergeInto(dst *Candidates, o2 *OneStat) *OneStat {
+	o, ok := dst.m[o2.k]
+	if !ok {
+		o = &OneStat{k: o2.k, index: -1, vindex: -1}
+		dst.m[o2.k] = o
+		heap.Push(dst.victims(), o)
+	}
+	o.reads += o2.reads
+	o.writes += o2.writes
+	o.conflicts += o2.conflicts
+	o.stash += o2.stash
+	o.mergeOps(o2.ops)
+	if o2.dd {
+		dst.promote(o)
+	}
+	return o
+}
+
+// MergeAll does a k-way merge of W workers' Candidates, picking off the
+// topM hottest keys without ever materializing a combined m map the size
+// of the union of all workers' keys. It seeds an outer priority queue
+// with the current head of each worker's StatsHeap, repeatedly pops the
+// overall max, folds it into the result (combining entries for the same
+// Key seen from multiple workers as they arrive), and pushes that
+// worker's next entry in its place. It stops once topM distinct keys
+// have been produced or the next candidate's ratio drops below WRRatio,
+// whichever comes first.
+func MergeAll(cs []*Candidates, topM int) *Candidates {
+	out := &Candidates{m: make(map[Key]*OneStat), h: &StatsHeap{}}
+	pq := &mergeQueue{}
+	for i, c := range cs {
+		if c == nil || len(*c.h) == 0 {
+			continue
+		}
+		o := heap.Pop(c.h).(*OneStat)
+		heap.Push(pq, &mergeItem{stat: o, worker: i})
+	}
+
+	for pq.Len() > 0 {
+		item := heap.Pop(pq).(*mergeItem)
+		o2 := item.stat
+		if o2.ratio() < *WRRatio {
+			break
+		}
+		if _, ok := out.m[o2.k]; !ok && len(out.m) >= topM {
+			break
+		}
+		o := mergeInto(out, o2)
+		out.h.update(o)
+		out.syncVictim(o)
+
+		c := cs[item.worker]
+		if len(*c.h) > 0 {
+			next := heap.Pop(c.h).(*OneStat)
+			heap.Push(pq, &mergeItem{stat: next, worker: item.worker})
+		}
+	}
+	return out
+}
+
+// MergeAllStream is the streaming twin of MergeAll for callers that can
+// produce a single fanned-in channel of OneStat updates (e.g. from a
+// pipeline merging worker shards) instead of holding every worker's
+// Candidates in memory at once. It keeps only the topM hottest keys,
+// evicting the lowest-ratio evictable entry whenever the bound is
+// exceeded.
+func MergeAllStream(ch <-chan *OneStat, topM int) *Candidates {
+	out := &Candidates{m: make(map[Key]*OneStat), h: &StatsHeap{}}
+	for o2 := range ch {
+		o := mergeInto(out, o2)
+		out.syncVictim(o)
+		if len(out.m) > topM {
+			v := out.victims()
+			if v.Len() > 0 {
+				worst := heap.Pop(v).(*OneStat)
+				delete(out.m, worst.k)
+				out.Evictions++
+			}
+		}
+	}
+	// Survivors only go into h once the stream is drained, since an
+	// entry in h is pinned out of v and could no longer be evicted.
+	for _, o := range out.m {
+		out.h.update(o)
+		out.syncVictim(o)
+	}
+	return out
+}
+
 func (c *Candidates) Read(k Key, br *BRecord) {
 	o, ok := c.m[k]
 	if !ok {
-		c.m[k] = &OneStat{k: k, op: -1, reads: 1, writes: 0, conflicts: 0, stash: 0, index: -1}
-		o = c.m[k]
+		o = c.newStat(k, 1, 0, 0, 0)
 	} else {
 		o.reads++
 	}
-	if o.ratio() > *WRRatio || (br != nil && br.dd) {
+	if br != nil && br.dd {
+		c.promote(o)
+	}
+	if o.ratio() > *WRRatio || o.dd {
 		c.h.update(o)
 	}
+	c.syncVictim(o)
 }
 
 // This is only used when a key is in split mode (can't count
@@ -77,66 +615,121 @@ func (c *Candidates) Read(k Key, br *BRecord) {
 func (c *Candidates) Write(k Key, br *BRecord, op KeyType) {
 	o, ok := c.m[k]
 	if !ok {
-		c.m[k] = &OneStat{k: k, op: op, reads: 1, writes: 1, conflicts: 0, stash: 0, index: -1}
-		o = c.m[k]
+		o = c.newStat(k, 1, 1, 0, 0)
 	} else {
-		if o.op == -1 {
-			o.op = op
-		}
-		if op != o.op {
-			debug.PrintStack()
-			log.Fatalf("Do not support multiple types of writes right now key %v, op write: %v op was: %v\n", k, op, o.op)
-		}
 		o.writes++
 	}
-	if (o.ratio() > *WRRatio && o.conflicts > 1) || (br != nil && br.dd) {
+	o.recordOp(op, 1, 0)
+	if br != nil && br.dd {
+		c.promote(o)
+	}
+	if (o.ratio() > *WRRatio && o.conflicts > 1) || o.dd {
 		c.h.update(o)
 	}
+	c.syncVictim(o)
 }
 
 func (c *Candidates) Conflict(k Key, br *BRecord, op KeyType) {
 	o, ok := c.m[k]
 	if !ok {
-		c.m[k] = &OneStat{k: k, op: op, reads: 1, writes: 0, conflicts: 1, stash: 0, index: -1}
-		o = c.m[k]
+		o = c.newStat(k, 1, 0, 1, 0)
 	} else {
-		if o.op == -1 {
-			o.op = op
-		}
-		if op != o.op {
-			debug.PrintStack()
-			log.Fatalf("Do not support multiple types of writes right now key %v, op conflict: %v op was: %v\n", k, op, o.op)
-		}
 		o.conflicts++
 	}
-	if o.ratio() > *WRRatio || (br != nil && br.dd) {
+	o.recordOp(op, 0, 1)
+	if br != nil && br.dd {
+		c.promote(o)
+	}
+	if o.ratio() > *WRRatio || o.dd {
 		c.h.update(o)
 	}
+	c.syncVictim(o)
 }
 
 func (c *Candidates) Stash(k Key) {
 	o, ok := c.m[k]
 	if !ok {
-		c.m[k] = &OneStat{k: k, op: -1, reads: 0, writes: 0, conflicts: 0, stash: 1, index: -1}
-		o = c.m[k]
+		o = c.newStat(k, 0, 0, 0, 1)
 	} else {
 		o.stash++
 	}
 	c.h.update(o)
+	c.syncVictim(o)
 }
 
 func (c *Candidates) ReadWrite(k Key, br *BRecord) {
 	o, ok := c.m[k]
 	if !ok {
-		c.m[k] = &OneStat{k: k, op: -1, reads: 5, writes: 0, conflicts: 0, stash: 0, index: -1}
-		o = c.m[k]
+		o = c.newStat(k, 5, 0, 0, 0)
 	} else {
 		o.reads = o.reads + 10
 		o.conflicts = o.conflicts - 1
 	}
-	if o.ratio() > *WRRatio || o.index > -1 || br.dd {
+	if br.dd {
+		c.promote(o)
+	}
+	if o.ratio() > *WRRatio || o.index > -1 || o.dd {
 		c.h.update(o)
 	}
+	c.syncVictim(o)
+}
+
+// Tick decays every tracked key's write and conflict counters (reads and
+// stash are left as they are) and re-sorts the heap entries whose
+// ratio moved as a result.  The decay applied is DecayAlpha scaled by how
+// much of a TickInterval has actually elapsed since the previous call, so
+// an uneven tick cadence (a slow stats collector, a GC pause) doesn't
+// over- or under-decay relative to ticking on schedule. A dd-marked key
+// whose decayed ratio stays below WRRatio for LowWaterTicks consecutive
+// calls is demoted (its dd bit is cleared) and its Key is returned to the
+// caller, which should move it back out of split mode.
+func (c *Candidates) Tick(now time.Time) []Key {
+	decay := *DecayAlpha
+	if !c.lastTick.IsZero() && *TickInterval > 0 {
+		elapsed := now.Sub(c.lastTick)
+		decay = math.Pow(*DecayAlpha, float64(elapsed)/float64(*TickInterval))
+	}
+	c.lastTick = now
+
+	var demoted []Key
+	for _, o := range c.m {
+		// ratio() is homogeneous degree 0, so scaling every counter by
+		// the same factor leaves it unchanged. Only decay the
+		// contention counters (writes, conflicts); reads/stash are the
+		// denominator and are left alone so a key that goes idle
+		// actually sees its ratio fall toward zero instead of holding
+		// steady forever.
+		o.writes *= decay
+		o.conflicts *= decay
+		// ops has to decay in step with the scalars above, or SplitOp
+		// keeps weighing never-fading per-op totals against the
+		// (correctly decaying) scalar ones and drifts unboundedly.
+		for i := range o.ops {
+			o.ops[i].Writes *= decay
+			o.ops[i].Conflicts *= decay
+		}
+		if o.index != -1 {
+			c.h.update(o)
+		}
+		if o.dd {
+			if o.ratio() < *WRRatio {
+				o.lowTicks++
+				if o.lowTicks >= *LowWaterTicks {
+					c.demote(o)
+					o.lowTicks = 0
+					demoted = append(demoted, o.k)
+					if o.index != -1 {
+						heap.Remove(c.h, o.index)
+					}
+				}
+			} else {
+				o.lowTicks = 0
+			}
+		}
+		c.syncVictim(o)
+	}
+	c.refreshSnapshot()
+	return demoted
 }
 
 func (c *Candidates) Print() {
@@ -0,0 +1,75 @@
+package ddtxn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickDemotesIdleKey(t *testing.T) {
+	c := &Candidates{m: make(map[Key]*OneStat), h: &StatsHeap{}}
+	k := Key(1)
+	c.Write(k, &BRecord{dd: true}, KeyType(0))
+
+	o := c.m[k]
+	if !o.dd {
+		t.Fatalf("expected %v to be marked dd after a Write with br.dd set", k)
+	}
+
+	now := time.Now()
+	var demoted []Key
+	for i := 0; i < *LowWaterTicks; i++ {
+		now = now.Add(*TickInterval)
+		demoted = c.Tick(now)
+	}
+
+	if len(demoted) != 1 || demoted[0] != k {
+		t.Fatalf("expected %v demoted after %d idle ticks, got %v", k, *LowWaterTicks, demoted)
+	}
+	if o.dd {
+		t.Fatalf("expected dd cleared once demoted")
+	}
+}
+
+func TestMergeAllStopsAtTopM(t *testing.T) {
+	hot := func(k Key, conflicts int) *Candidates {
+		c := &Candidates{m: make(map[Key]*OneStat), h: &StatsHeap{}}
+		for i := 0; i < conflicts; i++ {
+			c.Conflict(k, nil, KeyType(0))
+		}
+		return c
+	}
+
+	c1 := hot(Key(1), 5)
+	c2 := hot(Key(2), 10)
+
+	out := MergeAll([]*Candidates{c1, c2}, 1)
+	if len(out.m) != 1 {
+		t.Fatalf("MergeAll with topM=1 returned %d keys, want 1", len(out.m))
+	}
+	if _, ok := out.m[Key(2)]; !ok {
+		t.Fatalf("expected the hotter key %v to win the topM=1 cutoff", Key(2))
+	}
+}
+
+func TestMergeAllStopsBelowWRRatio(t *testing.T) {
+	hot := func(k Key, conflicts int) *Candidates {
+		c := &Candidates{m: make(map[Key]*OneStat), h: &StatsHeap{}}
+		for i := 0; i < conflicts; i++ {
+			c.Conflict(k, nil, KeyType(0))
+		}
+		return c
+	}
+
+	c1 := hot(Key(1), 5)
+
+	cold := &Candidates{m: make(map[Key]*OneStat), h: &StatsHeap{}}
+	cold.Read(Key(2), &BRecord{dd: true}) // forced into h despite a low ratio
+
+	out := MergeAll([]*Candidates{c1, cold}, 10)
+	if _, ok := out.m[Key(2)]; ok {
+		t.Fatalf("expected the below-WRRatio key %v to be excluded", Key(2))
+	}
+	if _, ok := out.m[Key(1)]; !ok {
+		t.Fatalf("expected the above-WRRatio key %v to be included", Key(1))
+	}
+}